@@ -26,72 +26,135 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	yaml "gopkg.in/yaml.v2"
 )
 
 type InputConfigParams struct {
 	InProfile string
 	OutFile   string
 	Rules     string
+	RulesDir  string
+	Report    string
+	DryRun    bool
 	Force     bool
+
+	ToURL     string
+	ToUser    string
+	ToPass    string
+	ProfileID string
+	Push      bool
 }
 
 // TrafficOps Profile Parsing
 type Profile struct {
-	Parameters  []Parameter `json:"parameters"`
-	Description ProfileDesc `json:"profile"`
+	Parameters  []Parameter `json:"parameters" yaml:"parameters"`
+	Description ProfileDesc `json:"profile" yaml:"profile"`
 }
 
 type Parameter struct {
-	Name       string `json:"name"`
-	ConfigFile string `json:"config_file"`
-	Value      string `json:"value"`
+	Name       string `json:"name" yaml:"name"`
+	ConfigFile string `json:"config_file" yaml:"config_file"`
+	Value      string `json:"value" yaml:"value"`
 }
 
 type ProfileDesc struct {
-	Description string `json:"description"`
-	Name        string `json:"name"`
-	Type        string `json:"type"`
+	Description string `json:"description" yaml:"description"`
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
 }
 
 // ConversionPolicy Parsing
 type ConversionPolicy struct {
-	ValidateParameters []Parameter      `json:"validate_parameters"`
-	ReplaceName        ReplaceRule      `json:"replace_name"`
-	ReplaceDescription ReplaceRule      `json:"replace_description"`
-	ConversionRules    []ConversionRule `json:"conversion_actions"`
+	ValidateParameters []Parameter      `json:"validate_parameters" yaml:"validate_parameters"`
+	ReplaceName        ReplaceRule      `json:"replace_name" yaml:"replace_name"`
+	ReplaceDescription ReplaceRule      `json:"replace_description" yaml:"replace_description"`
+	ConversionRules    []ConversionRule `json:"conversion_actions" yaml:"conversion_actions"`
 }
 
 type ReplaceRule struct {
-	Old string `json:"old"`
-	New string `json:"new"`
+	Old string `json:"old" yaml:"old"`
+	New string `json:"new" yaml:"new"`
 }
 
+// ConversionRule describes a single parameter transformation. MatchParameter
+// is required for every action except "add", since an "add" rule introduces
+// a parameter that by definition isn't present in the input profile yet.
+// MatchParameter's fields are regular expressions, with wildcard shorthand
+// such as "*.config" supported via expandWildcard. Where, if set, is an
+// additional boolean predicate ANDed with MatchParameter, which can consult
+// other parameters in the same Profile (see PredicateSpec).
 type ConversionRule struct {
-	MatchParameter Parameter `json:"match_parameter"`
-	NewName        string    `json:"new_name"`
-	NewConfigFile  string    `json:"new_config_file"`
-	NewValue       string    `json:"new_value"`
-	Action         string    `json:"action"`
+	MatchParameter Parameter       `json:"match_parameter" yaml:"match_parameter"`
+	Where          *PredicateSpec  `json:"where,omitempty" yaml:"where,omitempty"`
+	NewName        string          `json:"new_name" yaml:"new_name"`
+	NewConfigFile  string          `json:"new_config_file" yaml:"new_config_file"`
+	NewValue       string          `json:"new_value" yaml:"new_value"`
+	ValueTransform *ValueTransform `json:"value_transform,omitempty" yaml:"value_transform,omitempty"`
+	Action         string          `json:"action" yaml:"action"`
+}
+
+// expandWildcard rewrites glob-style shorthand such as "*.config" into the
+// equivalent regular expression by turning "*" into ".*". A field that
+// already compiles as a valid regex (e.g. the common ".*" or "a*b" idioms)
+// is left completely untouched, since that's the hand-written-regex case
+// this shorthand is meant to coexist with, not replace; only a field that
+// fails to compile as-is (a bare leading "*" is not a valid quantifier) is
+// treated as glob shorthand and expanded.
+func expandWildcard(field string) string {
+	if _, err := regexp.Compile(field); err == nil {
+		return field
+	}
+	return strings.ReplaceAll(field, "*", ".*")
 }
 
 func formatParam(p Parameter) string {
 	return fmt.Sprintf(`{"%s", "%s", "%s"}`, p.Name, p.ConfigFile, p.Value)
 }
 
+// formatParamPtr is formatParam for a ChangeRecord's Old/New, which are nil
+// when not applicable to that record's Type.
+func formatParamPtr(p *Parameter) string {
+	if p == nil {
+		return "-"
+	}
+	return formatParam(*p)
+}
+
 // Applies the rule represented by cr to the input parameter.
 //   Any non-empty string value will be replaced in the input with its new value
 //   Additionally an action may indicate a non-replacement operation, such as delete
-func (cr ConversionRule) Apply(param Parameter) (Parameter, bool) {
-	inParam := formatParam(param)
-
-	if cr.Action == "delete" {
-		fmt.Fprintf(os.Stderr, "Deleting parameter %s\n", inParam)
+//   or rename_only, which updates the name/config_file without touching the value
+// Every add/delete/modify/skip decision is recorded in log against ruleIndex
+// instead of being printed directly, so callers can review a full audit
+// trail before committing the mutated profile back to Traffic Ops.
+func (cr ConversionRule) Apply(param Parameter, ruleIndex int, log *ChangeLog) (Parameter, bool) {
+	original := param
+
+	switch cr.Action {
+	case "delete":
+		log.Add(ChangeRecord{Type: ChangeDelete, RuleIndex: ruleIndex, Old: &original})
 		return param, false
 
-	} else if cr.Action != "" {
-		fmt.Fprintf(os.Stderr, "[WARNING] Unknown action %s, skipping action\n", cr.Action)
+	case "rename_only":
+		if cr.NewName != "" {
+			param.Name = cr.NewName
+		}
+		if cr.NewConfigFile != "" {
+			param.ConfigFile = cr.NewConfigFile
+		}
+		log.Add(ChangeRecord{Type: ChangeModify, RuleIndex: ruleIndex, Old: &original, New: &param})
+		return param, true
+
+	case "", "add":
+		// "add" rules are inserted by applyAddRules and never reach here; an
+		// empty action is the default match-and-mutate behavior below.
+
+	default:
+		log.Add(ChangeRecord{Type: ChangeSkip, RuleIndex: ruleIndex, Old: &original, Reason: fmt.Sprintf("unknown action %q", cr.Action)})
 	}
 
 	if cr.NewName != "" {
@@ -105,7 +168,17 @@ func (cr ConversionRule) Apply(param Parameter) (Parameter, bool) {
 	if cr.NewValue != "" {
 		param.Value = cr.NewValue
 	}
-	fmt.Fprintf(os.Stderr, "Updating parameter %s to %s\n", inParam, formatParam(param))
+
+	if cr.ValueTransform != nil {
+		newValue, err := cr.ValueTransform.apply(param.Value, param)
+		if err != nil {
+			log.Add(ChangeRecord{Type: ChangeSkip, RuleIndex: ruleIndex, Old: &original, Reason: fmt.Sprintf("value_transform: %s", err)})
+		} else {
+			param.Value = newValue
+		}
+	}
+
+	log.Add(ChangeRecord{Type: ChangeModify, RuleIndex: ruleIndex, Old: &original, New: &param})
 
 	return param, true
 }
@@ -114,53 +187,100 @@ func parseArgs() InputConfigParams {
 	inputConfig := InputConfigParams{}
 	flag.StringVar(&inputConfig.InProfile, "input_profile", "", "Path of input profile")
 	flag.StringVar(&inputConfig.Rules, "rules", "", "Path to conversion rules")
+	flag.StringVar(&inputConfig.RulesDir, "rules_dir", "", "Path to a directory of conversion rules files, applied in sorted filename order as a pipeline of stages")
 	flag.StringVar(&inputConfig.OutFile, "out", "", "Path to write output file to. If not given, uses stdout")
+	flag.StringVar(&inputConfig.Report, "report", "", "Path to write a report of every add/delete/modify/skip change to. Written as JSON if the path ends in .json, otherwise as a unified text diff")
+	flag.BoolVar(&inputConfig.DryRun, "dry_run", false, "Run the full conversion and write the report, but do not write the output profile")
 	flag.BoolVar(&inputConfig.Force, "force", false, "Ignore parameter value, making all recommended changes")
+	flag.StringVar(&inputConfig.ToURL, "to_url", "", "Base URL of a Traffic Ops instance to fetch the input profile from, instead of -input_profile")
+	flag.StringVar(&inputConfig.ToUser, "to_user", "", "Traffic Ops username, required with -to_url")
+	flag.StringVar(&inputConfig.ToPass, "to_pass", "", "Traffic Ops password, required with -to_url")
+	flag.StringVar(&inputConfig.ProfileID, "profile_id", "", "ID of the profile to fetch/push in Traffic Ops, required with -to_url")
+	flag.BoolVar(&inputConfig.Push, "push", false, "PUT the converted profile back to Traffic Ops; requires -to_url")
 	flag.Parse()
 
-	if inputConfig.InProfile == "" {
-		fmt.Fprintf(os.Stderr, "[ERROR] Missing required -input_profile parameter\n")
+	if inputConfig.InProfile == "" && inputConfig.ToURL == "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] Missing required -input_profile or -to_url parameter\n")
+		os.Exit(1)
+	}
+
+	if inputConfig.InProfile != "" && inputConfig.ToURL != "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] -input_profile and -to_url are mutually exclusive\n")
+		os.Exit(1)
+	}
+
+	if inputConfig.ToURL != "" && inputConfig.ProfileID == "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] -to_url requires -profile_id\n")
+		os.Exit(1)
+	}
+
+	if inputConfig.Push && inputConfig.ToURL == "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] -push requires -to_url\n")
+		os.Exit(1)
+	}
+
+	if inputConfig.Rules == "" && inputConfig.RulesDir == "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] Missing required -rules or -rules_dir parameter\n")
 		os.Exit(1)
 	}
 
-	if inputConfig.Rules == "" {
-		fmt.Fprintf(os.Stderr, "[ERROR] Missing required -rules parameter\n")
+	if inputConfig.Rules != "" && inputConfig.RulesDir != "" {
+		fmt.Fprintf(os.Stderr, "[ERROR] -rules and -rules_dir are mutually exclusive\n")
 		os.Exit(1)
 	}
 
 	return inputConfig
 }
 
-func readFile(inFile string) []byte {
-	file, err := ioutil.ReadFile(inFile)
+// parseInputRules accepts either a JSON or a YAML rules file, as
+// parseConversionPolicy does, but panics on failure since it's used directly
+// by main for the single -rules case, where a fatal startup error has always
+// been reported this way.
+func parseInputRules(inFile string) *ConversionPolicy {
+	cp, err := parseConversionPolicy(inFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Cannot open input file: %s\n", inFile)
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
 		panic(err)
 	}
 
-	return file
+	return cp
 }
 
-func parseInputProfile(inFile string) *Profile {
-	var pt Profile
-	err := json.Unmarshal(readFile(inFile), &pt)
+// parseConversionPolicy accepts either a JSON or a YAML rules file. The
+// format is picked by the file extension (.yaml/.yml vs .json); when the
+// extension is anything else, the content is sniffed since a JSON rules file
+// always begins with '{' once leading whitespace is trimmed.
+func parseConversionPolicy(inFile string) (*ConversionPolicy, error) {
+	var cp ConversionPolicy
+
+	data, err := ioutil.ReadFile(inFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Cannot parse input profile\n")
-		panic(err)
+		return nil, fmt.Errorf("cannot open input file %s: %w", inFile, err)
 	}
 
-	return &pt
-}
+	if isYAMLRules(inFile, data) {
+		err = yaml.Unmarshal(data, &cp)
+	} else {
+		err = json.Unmarshal(data, &cp)
+	}
 
-func parseInputRules(inFile string) *ConversionPolicy {
-	var cp ConversionPolicy
-	err := json.Unmarshal(readFile(inFile), &cp)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Cannot parse conversion rules\n")
-		panic(err)
+		return nil, fmt.Errorf("cannot parse conversion rules %s: %w", inFile, err)
 	}
 
-	return &cp
+	return &cp, nil
+}
+
+func isYAMLRules(inFile string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(inFile)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) == 0 || trimmed[0] != '{'
 }
 
 // ValidateParameters will verify that all parameters in paramsToValidate appear
@@ -194,19 +314,36 @@ func ValidateParameters(profile *Profile,
 // ConvertProfile will modify parameters as described by matching entries in conversionActions
 // If ignoreValue is set to true, the Value field in matcher will be ignored, effectively matching
 // all values
+// Every decision made along the way is recorded in log.
+//
+// filteredParams below compacts into profile.Parameters' own backing array as
+// it goes, so Where/profile_has predicates are evaluated against original, a
+// snapshot taken before the loop starts, rather than that live, partially
+// overwritten slice.
 func ConvertProfile(profile *Profile,
 	rules []ConversionRule,
-	ignoreValue bool) {
+	ignoreValue bool,
+	log *ChangeLog) {
+	original := make([]Parameter, len(profile.Parameters))
+	copy(original, profile.Parameters)
+	originalProfile := &Profile{Parameters: original, Description: profile.Description}
+
 	filteredParams := profile.Parameters[:0]
 
-	for _, param := range profile.Parameters {
+	for paramIndex, param := range original {
 
 		matched := false
-		for _, rule := range rules {
-			if paramsMatch(rule.MatchParameter, param, ignoreValue) {
+		for ruleIndex, rule := range rules {
+			if rule.Action == "add" {
+				// "add" rules don't match existing parameters; they are
+				// applied once below, after every parameter has been visited.
+				continue
+			}
+
+			if paramsMatch(rule, param, paramIndex, originalProfile, ignoreValue, ruleIndex, log) {
 				matched = true
 
-				updatedParam, keep := rule.Apply(param)
+				updatedParam, keep := rule.Apply(param, ruleIndex, log)
 				if keep {
 					filteredParams = append(filteredParams, updatedParam)
 				}
@@ -221,25 +358,77 @@ func ConvertProfile(profile *Profile,
 		}
 	}
 
-	profile.Parameters = filteredParams
+	profile.Parameters = applyAddRules(filteredParams, rules, log)
 }
 
-// paramsMatch returns true when param fulfills all matching criteria in matcher
-func paramsMatch(matcher Parameter, param Parameter, ignoreValue bool) bool {
-	nameRe := regexp.MustCompile(matcher.Name)
-	cfgRe := regexp.MustCompile(matcher.ConfigFile)
-	valueRe := regexp.MustCompile(matcher.Value)
+// applyAddRules inserts the parameter described by each "add" rule into
+// params, skipping any rule whose parameter is already present so that
+// re-running a conversion is idempotent.
+func applyAddRules(params []Parameter, rules []ConversionRule, log *ChangeLog) []Parameter {
+	for ruleIndex, rule := range rules {
+		if rule.Action != "add" {
+			continue
+		}
+
+		newParam := Parameter{Name: rule.NewName, ConfigFile: rule.NewConfigFile, Value: rule.NewValue}
+
+		exists := false
+		for _, p := range params {
+			if p.Name == newParam.Name && p.ConfigFile == newParam.ConfigFile {
+				exists = true
+				break
+			}
+		}
+
+		if exists {
+			log.Add(ChangeRecord{Type: ChangeSkip, RuleIndex: ruleIndex, New: &newParam, Reason: "parameter already present in profile"})
+			continue
+		}
+
+		log.Add(ChangeRecord{Type: ChangeAdd, RuleIndex: ruleIndex, New: &newParam})
+		params = append(params, newParam)
+	}
+
+	return params
+}
+
+// paramsMatch returns true when param fulfills all matching criteria in
+// rule.MatchParameter, and rule.Where if set. When ignoreValue is false and
+// the name/config_file match but the value does not, the near-miss is
+// recorded in log as a skip rather than printed directly, so operators can
+// review it before committing the mutated profile.
+func paramsMatch(rule ConversionRule, param Parameter, paramIndex int, profile *Profile, ignoreValue bool, ruleIndex int, log *ChangeLog) bool {
+	matcher := rule.MatchParameter
+	nameRe := regexp.MustCompile(expandWildcard(matcher.Name))
+	cfgRe := regexp.MustCompile(expandWildcard(matcher.ConfigFile))
+	valueRe := regexp.MustCompile(expandWildcard(matcher.Value))
 
 	if nil != nameRe.FindStringIndex(param.Name) &&
 		nil != cfgRe.FindStringIndex(param.ConfigFile) {
 
-		if ignoreValue || nil != valueRe.FindStringIndex(param.Value) {
-			return true
+		if !ignoreValue && nil == valueRe.FindStringIndex(param.Value) {
+			log.Add(ChangeRecord{
+				Type:      ChangeSkip,
+				RuleIndex: ruleIndex,
+				Old:       &param,
+				Reason:    "parameter value does not match rule and -force was not given; update manually",
+			})
+			return false
+		}
 
-		} else {
-			fmt.Fprintf(os.Stderr, "[ACTION REQUIRED] Found modified value. Skip modifying {\"%s\", \"%s\", \"%s\"}. Please update manually\n",
-				param.Name, param.ConfigFile, param.Value)
+		if rule.Where != nil {
+			pred, err := rule.Where.Build()
+			if err != nil {
+				log.Add(ChangeRecord{Type: ChangeSkip, RuleIndex: ruleIndex, Old: &param, Reason: fmt.Sprintf("where: %s", err)})
+				return false
+			}
+
+			if !pred.Eval(param, paramIndex, profile) {
+				return false
+			}
 		}
+
+		return true
 	}
 	return false
 }
@@ -252,27 +441,77 @@ func UpdateDetails(p *Profile, rules *ConversionPolicy) {
 func main() {
 	cfgParam := parseArgs()
 	fmt.Fprintf(os.Stderr, "Traffic Control Profile Conversion Utility\n")
-	fmt.Fprintf(os.Stderr, "Input Profile: %s\n", cfgParam.InProfile)
-	fmt.Fprintf(os.Stderr, "Conversion Rules: %s\n", cfgParam.Rules)
+	if cfgParam.ToURL != "" {
+		fmt.Fprintf(os.Stderr, "Input Profile: Traffic Ops profile %s at %s\n", cfgParam.ProfileID, cfgParam.ToURL)
+	} else {
+		fmt.Fprintf(os.Stderr, "Input Profile: %s\n", cfgParam.InProfile)
+	}
 	if cfgParam.Force {
 		fmt.Fprintf(os.Stderr, "[WARNING] Ignoring existing parameter values in comparisons, making all suggested changes\n")
 	}
 
-	inProfile := parseInputProfile(cfgParam.InProfile)
-	rules := parseInputRules(cfgParam.Rules)
+	source := newProfileSource(cfgParam)
 
-	if !ValidateParameters(inProfile, rules.ValidateParameters) {
-		fmt.Fprintf(os.Stderr, "[ERROR] Failed to validate required parameters in profile\n")
+	inProfile, err := source.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] Cannot load input profile: %s\n", err)
 		os.Exit(-1)
 	}
-	ConvertProfile(inProfile, rules.ConversionRules, cfgParam.Force)
-	UpdateDetails(inProfile, rules)
+	log := &ChangeLog{}
+
+	if cfgParam.RulesDir != "" {
+		fmt.Fprintf(os.Stderr, "Conversion Rules Directory: %s\n", cfgParam.RulesDir)
+
+		pipeline, err := NewPipeline(cfgParam.RulesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+			os.Exit(-1)
+		}
+
+		if err := pipeline.Run(inProfile, cfgParam.Force, log); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+			os.Exit(-1)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Conversion Rules: %s\n", cfgParam.Rules)
+		rules := parseInputRules(cfgParam.Rules)
+
+		if !ValidateParameters(inProfile, rules.ValidateParameters) {
+			fmt.Fprintf(os.Stderr, "[ERROR] Failed to validate required parameters in profile\n")
+			os.Exit(-1)
+		}
+		ConvertProfile(inProfile, rules.ConversionRules, cfgParam.Force, log)
+		UpdateDetails(inProfile, rules)
+	}
+
+	if cfgParam.Report != "" {
+		if err := log.WriteReport(cfgParam.Report); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Cannot write report: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if cfgParam.DryRun {
+		fmt.Fprintf(os.Stderr, "[DRY RUN] Not writing output profile\n")
+		return
+	}
+
+	if cfgParam.Push {
+		if err := source.Save(inProfile); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] Cannot push converted profile: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	writeOutputProfile(inProfile, cfgParam.OutFile)
+}
 
+func writeOutputProfile(profile *Profile, outFile string) {
 	// Can't use the standard JSON Marshaller because it forces HTML escape
 	buf := new(bytes.Buffer)
 	enc := json.NewEncoder(buf)
 	enc.SetEscapeHTML(false)
-	if err := enc.Encode(inProfile); err != nil {
+	if err := enc.Encode(profile); err != nil {
 		panic(err)
 	}
 
@@ -281,8 +520,8 @@ func main() {
 		panic(err)
 	}
 
-	if cfgParam.OutFile != "" {
-		err := ioutil.WriteFile(cfgParam.OutFile, indentedBuffer.Bytes(), 0644)
+	if outFile != "" {
+		err := ioutil.WriteFile(outFile, indentedBuffer.Bytes(), 0644)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[ERROR] Cannot write output file")
 			panic(err)
@@ -0,0 +1,91 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import "testing"
+
+func TestExpandWildcard(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{"bare wildcard shorthand", "*.config", ".*.config"},
+		{"already-valid dot-star idiom untouched", ".*", ".*"},
+		{"already-valid quantifier untouched", "a*b", "a*b"},
+		{"empty field untouched", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandWildcard(tt.field); got != tt.want {
+				t.Errorf("expandWildcard(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsMatchPreservesExistingDotStarRules(t *testing.T) {
+	log := &ChangeLog{}
+	rule := ConversionRule{MatchParameter: Parameter{Name: ".*", ConfigFile: ".*", Value: ".*"}}
+
+	if !paramsMatch(rule, Parameter{Name: "foo", ConfigFile: "bar.config", Value: ""}, 0, nil, false, 0, log) {
+		t.Fatal("rule using the pre-existing \".*\" idiom should still match an empty value")
+	}
+}
+
+// TestConvertProfileWhereSeesPreConversionSnapshot guards against
+// ConvertProfile's in-place compaction of profile.Parameters leaking into
+// Where/profile_has evaluation. With enough parameters between the renamed
+// one and the one consulting profile_has, a predicate reading the live,
+// partially-compacted profile.Parameters directly would miss the renamed
+// parameter's original name; reading the pre-loop snapshot instead must not.
+func TestConvertProfileWhereSeesPreConversionSnapshot(t *testing.T) {
+	profile := &Profile{Parameters: []Parameter{
+		{Name: "X", ConfigFile: "records.config", Value: "1"},
+		{Name: "Y", ConfigFile: "records.config", Value: "2"},
+		{Name: "W", ConfigFile: "records.config", Value: "3"},
+		{Name: "Z", ConfigFile: "records.config", Value: "4"},
+	}}
+
+	rules := []ConversionRule{
+		{MatchParameter: Parameter{Name: "X"}, Action: "delete"},
+		{MatchParameter: Parameter{Name: "Y"}, NewName: "Y2", Action: "rename_only"},
+		{
+			MatchParameter: Parameter{Name: "Z"},
+			Where:          &PredicateSpec{Not: &PredicateSpec{ProfileHas: &ProfileHasMatcher{Name: "^Y$", ConfigFile: ".*"}}},
+			Action:         "delete",
+		},
+	}
+
+	ConvertProfile(profile, rules, false, &ChangeLog{})
+
+	var names []string
+	for _, p := range profile.Parameters {
+		names = append(names, p.Name)
+	}
+
+	want := []string{"Y2", "W", "Z"}
+	if len(names) != len(want) {
+		t.Fatalf("got names %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got names %v, want %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ChangeType identifies the kind of event a ChangeRecord describes.
+type ChangeType string
+
+const (
+	ChangeAdd    ChangeType = "add"
+	ChangeDelete ChangeType = "delete"
+	ChangeModify ChangeType = "modify"
+	ChangeSkip   ChangeType = "skip"
+)
+
+// ChangeRecord is a single typed audit entry produced while converting a
+// profile: a parameter was added, deleted, modified, or skipped by the rule
+// at RuleIndex. Old/New are populated as makes sense for Type; e.g. an add
+// only has New, a delete only has Old. Both are pointers so the unset one is
+// omitted from JSON output instead of marshaling as a zero-valued Parameter.
+type ChangeRecord struct {
+	Type      ChangeType `json:"type"`
+	Stage     string     `json:"stage,omitempty"`
+	RuleIndex int        `json:"rule_index"`
+	Old       *Parameter `json:"old,omitempty"`
+	New       *Parameter `json:"new,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// ChangeLog accumulates every add/delete/modify/skip decision made while
+// converting a profile, replacing the ad-hoc fmt.Fprintf logging this used
+// to do directly to stderr.
+type ChangeLog struct {
+	Records []ChangeRecord `json:"records"`
+	stage   string
+	parent  *ChangeLog
+}
+
+// Add appends r to the log, or to the root log if this is a stage-scoped
+// view returned by ForStage.
+func (c *ChangeLog) Add(r ChangeRecord) {
+	if c.stage != "" {
+		r.Stage = c.stage
+	}
+
+	if c.parent != nil {
+		c.parent.Add(r)
+		return
+	}
+
+	c.Records = append(c.Records, r)
+}
+
+// ForStage returns a ChangeLog view that shares this log's storage but
+// stamps every record added through it with the given stage name.
+func (c *ChangeLog) ForStage(name string) *ChangeLog {
+	return &ChangeLog{stage: name, parent: c}
+}
+
+// WriteReport writes the log to path, as JSON if path ends in ".json" and
+// as a unified text diff otherwise.
+func (c *ChangeLog) WriteReport(path string) error {
+	var data []byte
+	var err error
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		data, err = json.MarshalIndent(c, "", "    ")
+	} else {
+		data = []byte(c.TextDiff())
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot format report: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("cannot write report file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// TextDiff renders the log as a unified textual diff, one line per record.
+func (c *ChangeLog) TextDiff() string {
+	var b strings.Builder
+
+	for _, r := range c.Records {
+		prefix := ""
+		if r.Stage != "" {
+			prefix = fmt.Sprintf("[%s] ", r.Stage)
+		}
+
+		switch r.Type {
+		case ChangeAdd:
+			fmt.Fprintf(&b, "%s+ [rule %d] %s\n", prefix, r.RuleIndex, formatParamPtr(r.New))
+		case ChangeDelete:
+			fmt.Fprintf(&b, "%s- [rule %d] %s\n", prefix, r.RuleIndex, formatParamPtr(r.Old))
+		case ChangeModify:
+			fmt.Fprintf(&b, "%s~ [rule %d] %s -> %s\n", prefix, r.RuleIndex, formatParamPtr(r.Old), formatParamPtr(r.New))
+		case ChangeSkip:
+			fmt.Fprintf(&b, "%s! [rule %d] %s (%s)\n", prefix, r.RuleIndex, formatParamPtr(r.Old), r.Reason)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,41 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChangeRecordOmitsUnsetOldOrNew(t *testing.T) {
+	add, err := json.Marshal(ChangeRecord{Type: ChangeAdd, New: &Parameter{Name: "foo"}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(add), `"old"`) {
+		t.Errorf("add record should omit the unset \"old\" field: %s", add)
+	}
+
+	del, err := json.Marshal(ChangeRecord{Type: ChangeDelete, Old: &Parameter{Name: "bar"}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(del), `"new"`) {
+		t.Errorf("delete record should omit the unset \"new\" field: %s", del)
+	}
+}
@@ -0,0 +1,94 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pipeline applies an ordered series of ConversionPolicy stages to a single
+// Profile, one hop at a time (e.g. 6.2->7.0, 7.0->7.1, 7.1->8.0).
+type Pipeline struct {
+	Stages     []ConversionPolicy
+	StageNames []string
+}
+
+// rulesFileExtensions are the file extensions NewPipeline treats as rules
+// files; anything else in -rules_dir (editor backups, READMEs, .DS_Store)
+// is silently skipped rather than handed to the parser.
+var rulesFileExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true}
+
+// NewPipeline reads every rules file in dir, in sorted filename order, and
+// returns a Pipeline that will apply them as successive stages. Rule files
+// are expected to be named so that lexical sort order matches the intended
+// migration order, e.g. "01-6.2-to-7.0.yaml", "02-7.0-to-7.1.yaml".
+func NewPipeline(dir string) (Pipeline, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return Pipeline{}, fmt.Errorf("cannot read rules directory %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !rulesFileExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	pipeline := Pipeline{}
+	for _, name := range names {
+		cp, err := parseConversionPolicy(filepath.Join(dir, name))
+		if err != nil {
+			return Pipeline{}, fmt.Errorf("rules file %s: %w", name, err)
+		}
+		pipeline.Stages = append(pipeline.Stages, *cp)
+		pipeline.StageNames = append(pipeline.StageNames, name)
+	}
+
+	return pipeline, nil
+}
+
+// Run applies each stage in order to profile, re-validating the
+// intermediate profile against that stage's validate_parameters before
+// converting it. It stops and returns an error on the first stage that
+// fails validation, since later stages are expected to depend on the
+// parameters earlier stages introduce or rename. Every stage's decisions are
+// recorded in log, tagged with that stage's name, so the full migration can
+// be reviewed as a single report.
+func (p Pipeline) Run(profile *Profile, ignoreValue bool, log *ChangeLog) error {
+	for i, stage := range p.Stages {
+		if !ValidateParameters(profile, stage.ValidateParameters) {
+			return fmt.Errorf("stage %d (%s): failed to validate required parameters in profile", i, p.StageNames[i])
+		}
+
+		before := len(profile.Parameters)
+		ConvertProfile(profile, stage.ConversionRules, ignoreValue, log.ForStage(p.StageNames[i]))
+		UpdateDetails(profile, &stage)
+
+		fmt.Fprintf(os.Stderr, "=== Stage %d (%s) complete: %d parameters in, %d parameters out ===\n",
+			i, p.StageNames[i], before, len(profile.Parameters))
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeRulesFile(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile %s: %v", name, err)
+	}
+}
+
+func TestNewPipelineOrdersStagesByFilenameAndSkipsNonRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeRulesFile(t, dir, "02-stage-two.yaml", `
+conversion_actions:
+  - match_parameter: {name: b}
+    new_value: two
+`)
+	writeRulesFile(t, dir, "01-stage-one.yaml", `
+conversion_actions:
+  - match_parameter: {name: a}
+    new_value: one
+`)
+	writeRulesFile(t, dir, "README.md", "this is not a rules file")
+	writeRulesFile(t, dir, ".DS_Store", "not a rules file either")
+
+	pipeline, err := NewPipeline(dir)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	wantNames := []string{"01-stage-one.yaml", "02-stage-two.yaml"}
+	if len(pipeline.StageNames) != len(wantNames) {
+		t.Fatalf("got stage names %v, want %v", pipeline.StageNames, wantNames)
+	}
+	for i, want := range wantNames {
+		if pipeline.StageNames[i] != want {
+			t.Errorf("stage %d: got %q, want %q", i, pipeline.StageNames[i], want)
+		}
+	}
+}
+
+func TestNewPipelineReturnsErrorForUnparsableRulesFile(t *testing.T) {
+	dir := t.TempDir()
+	writeRulesFile(t, dir, "01-broken.yaml", "not: valid: yaml: [")
+
+	if _, err := NewPipeline(dir); err == nil {
+		t.Fatal("NewPipeline should return an error for an unparsable rules file")
+	}
+}
+
+func TestPipelineRunAppliesStagesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeRulesFile(t, dir, "01-rename-a.yaml", `
+conversion_actions:
+  - match_parameter: {name: a}
+    new_name: a2
+`)
+	writeRulesFile(t, dir, "02-rename-a2.yaml", `
+conversion_actions:
+  - match_parameter: {name: a2}
+    new_name: a3
+`)
+
+	pipeline, err := NewPipeline(dir)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	profile := &Profile{Parameters: []Parameter{{Name: "a", ConfigFile: "records.config", Value: "v"}}}
+	log := &ChangeLog{}
+	if err := pipeline.Run(profile, false, log); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(profile.Parameters) != 1 || profile.Parameters[0].Name != "a3" {
+		t.Fatalf("got %+v, want a single parameter renamed to a3 by both stages in order", profile.Parameters)
+	}
+
+	for _, r := range log.Records {
+		if r.Stage == "" {
+			t.Errorf("record %+v should be tagged with its stage name", r)
+		}
+	}
+}
+
+func TestPipelineRunStopsOnValidationFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeRulesFile(t, dir, "01-requires-b.yaml", `
+validate_parameters:
+  - name: b
+    config_file: records.config
+    value: ""
+conversion_actions:
+  - match_parameter: {name: a}
+    new_name: a2
+`)
+
+	pipeline, err := NewPipeline(dir)
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	profile := &Profile{Parameters: []Parameter{{Name: "a", ConfigFile: "records.config", Value: "v"}}}
+	if err := pipeline.Run(profile, false, &ChangeLog{}); err == nil {
+		t.Fatal("Run should fail when the profile doesn't satisfy a stage's validate_parameters")
+	}
+}
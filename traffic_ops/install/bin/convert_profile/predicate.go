@@ -0,0 +1,205 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Predicate is a single node of the boolean expression tree built from a
+// ConversionRule's Where field. Eval is given the parameter under
+// consideration, its index within profile.Parameters, and the full Profile,
+// so cross-parameter leaves like profile_has can consult sibling parameters
+// and exclude param itself by position rather than by value.
+type Predicate interface {
+	Eval(param Parameter, paramIndex int, profile *Profile) bool
+}
+
+// PredicateSpec is the JSON/YAML shape of a Where expression. Exactly one
+// field is expected to be set per node; AllOf, AnyOf, Not, NameMatches,
+// ConfigFileEquals, ValueMatches, and ProfileHas are tried in that order.
+type PredicateSpec struct {
+	AllOf []PredicateSpec `json:"all_of,omitempty" yaml:"all_of,omitempty"`
+	AnyOf []PredicateSpec `json:"any_of,omitempty" yaml:"any_of,omitempty"`
+	Not   *PredicateSpec  `json:"not,omitempty" yaml:"not,omitempty"`
+
+	NameMatches      string             `json:"name_matches,omitempty" yaml:"name_matches,omitempty"`
+	ConfigFileEquals string             `json:"config_file_equals,omitempty" yaml:"config_file_equals,omitempty"`
+	ValueMatches     string             `json:"value_matches,omitempty" yaml:"value_matches,omitempty"`
+	ProfileHas       *ProfileHasMatcher `json:"profile_has,omitempty" yaml:"profile_has,omitempty"`
+}
+
+// ProfileHasMatcher is the configuration for a profile_has leaf predicate:
+// it is satisfied when some parameter elsewhere in the Profile has a Name
+// and ConfigFile matching the given patterns.
+type ProfileHasMatcher struct {
+	Name       string `json:"name" yaml:"name"`
+	ConfigFile string `json:"config_file" yaml:"config_file"`
+}
+
+// Build compiles s into a Predicate, compiling every regex leaf up front so
+// Eval itself never fails. An error here is a malformed rules file, not a
+// runtime condition, so callers record it as a skip rather than panicking.
+func (s *PredicateSpec) Build() (Predicate, error) {
+	switch {
+	case len(s.AllOf) > 0:
+		subs, err := buildAll(s.AllOf)
+		if err != nil {
+			return nil, err
+		}
+		return allOfPredicate{subs}, nil
+
+	case len(s.AnyOf) > 0:
+		subs, err := buildAll(s.AnyOf)
+		if err != nil {
+			return nil, err
+		}
+		return anyOfPredicate{subs}, nil
+
+	case s.Not != nil:
+		sub, err := s.Not.Build()
+		if err != nil {
+			return nil, err
+		}
+		return notPredicate{sub}, nil
+
+	case s.NameMatches != "":
+		re, err := regexp.Compile(expandWildcard(s.NameMatches))
+		if err != nil {
+			return nil, fmt.Errorf("invalid name_matches pattern %q: %w", s.NameMatches, err)
+		}
+		return nameMatchesPredicate{re}, nil
+
+	case s.ConfigFileEquals != "":
+		return configFileEqualsPredicate{s.ConfigFileEquals}, nil
+
+	case s.ValueMatches != "":
+		re, err := regexp.Compile(expandWildcard(s.ValueMatches))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value_matches pattern %q: %w", s.ValueMatches, err)
+		}
+		return valueMatchesPredicate{re}, nil
+
+	case s.ProfileHas != nil:
+		nameRe, err := regexp.Compile(expandWildcard(s.ProfileHas.Name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile_has name pattern %q: %w", s.ProfileHas.Name, err)
+		}
+		cfgRe, err := regexp.Compile(expandWildcard(s.ProfileHas.ConfigFile))
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile_has config_file pattern %q: %w", s.ProfileHas.ConfigFile, err)
+		}
+		return profileHasPredicate{nameRe, cfgRe}, nil
+	}
+
+	return nil, fmt.Errorf("empty where predicate")
+}
+
+func buildAll(specs []PredicateSpec) ([]Predicate, error) {
+	preds := make([]Predicate, 0, len(specs))
+	for i := range specs {
+		pred, err := specs[i].Build()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	return preds, nil
+}
+
+type allOfPredicate struct {
+	subs []Predicate
+}
+
+func (p allOfPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	for _, sub := range p.subs {
+		if !sub.Eval(param, paramIndex, profile) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyOfPredicate struct {
+	subs []Predicate
+}
+
+func (p anyOfPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	for _, sub := range p.subs {
+		if sub.Eval(param, paramIndex, profile) {
+			return true
+		}
+	}
+	return false
+}
+
+type notPredicate struct {
+	sub Predicate
+}
+
+func (p notPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	return !p.sub.Eval(param, paramIndex, profile)
+}
+
+type nameMatchesPredicate struct {
+	re *regexp.Regexp
+}
+
+func (p nameMatchesPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	return p.re.MatchString(param.Name)
+}
+
+type configFileEqualsPredicate struct {
+	value string
+}
+
+func (p configFileEqualsPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	return param.ConfigFile == p.value
+}
+
+type valueMatchesPredicate struct {
+	re *regexp.Regexp
+}
+
+func (p valueMatchesPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	return p.re.MatchString(param.Value)
+}
+
+// profileHasPredicate is satisfied when some parameter in profile, other
+// than the one at paramIndex, has a Name and ConfigFile matching its
+// patterns.
+type profileHasPredicate struct {
+	nameRe *regexp.Regexp
+	cfgRe  *regexp.Regexp
+}
+
+func (p profileHasPredicate) Eval(param Parameter, paramIndex int, profile *Profile) bool {
+	if profile == nil {
+		return false
+	}
+
+	for i, other := range profile.Parameters {
+		if i == paramIndex {
+			continue
+		}
+		if p.nameRe.MatchString(other.Name) && p.cfgRe.MatchString(other.ConfigFile) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import "testing"
+
+func TestProfileHasPredicateExcludesBySelfIndexNotValue(t *testing.T) {
+	dup := Parameter{Name: "location", ConfigFile: "records.config", Value: "/opt"}
+	profile := &Profile{Parameters: []Parameter{dup, dup}}
+
+	spec := PredicateSpec{ProfileHas: &ProfileHasMatcher{Name: "location", ConfigFile: "records.config"}}
+	pred, err := spec.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if !pred.Eval(profile.Parameters[0], 0, profile) {
+		t.Fatal("profile_has should find the duplicate entry at index 1, not treat both as self")
+	}
+}
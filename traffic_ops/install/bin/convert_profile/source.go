@@ -0,0 +1,192 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+)
+
+// ProfileSource abstracts where an input Profile is read from and, when
+// -push is given, where the converted Profile is written back to. This lets
+// the rest of the tool stay ignorant of whether it's working against a
+// local JSON export or talking directly to a Traffic Ops instance.
+type ProfileSource interface {
+	Load() (*Profile, error)
+	Save(profile *Profile) error
+}
+
+// newProfileSource picks the ProfileSource described by cfg: a
+// TrafficOpsSource when -to_url is given, otherwise a FileSource reading
+// -input_profile.
+func newProfileSource(cfg InputConfigParams) ProfileSource {
+	if cfg.ToURL != "" {
+		return NewTrafficOpsSource(cfg.ToURL, cfg.ToUser, cfg.ToPass, cfg.ProfileID)
+	}
+
+	return FileSource{Path: cfg.InProfile}
+}
+
+// FileSource reads an input Profile from a local JSON file.
+type FileSource struct {
+	Path string
+}
+
+func (f FileSource) Load() (*Profile, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open input profile %s: %w", f.Path, err)
+	}
+
+	var pt Profile
+	if err := json.Unmarshal(data, &pt); err != nil {
+		return nil, fmt.Errorf("cannot parse input profile %s: %w", f.Path, err)
+	}
+
+	return &pt, nil
+}
+
+// Save is never reached in practice: parseArgs requires -to_url for -push,
+// so a FileSource is never asked to push a converted profile anywhere.
+func (f FileSource) Save(profile *Profile) error {
+	return fmt.Errorf("-push is not supported for a local file source; pass -to_url instead")
+}
+
+// TrafficOpsSource fetches and pushes a Profile directly via the Traffic
+// Ops API, eliminating the manual export/import round-trip operators
+// otherwise have to do through local files.
+type TrafficOpsSource struct {
+	URL       string
+	User      string
+	Pass      string
+	ProfileID string
+
+	client *http.Client
+}
+
+// NewTrafficOpsSource builds a TrafficOpsSource against the given Traffic
+// Ops instance. Login happens lazily: every Load and every Save call logs in
+// before making its request.
+func NewTrafficOpsSource(url, user, pass, profileID string) *TrafficOpsSource {
+	jar, _ := cookiejar.New(nil)
+
+	return &TrafficOpsSource{
+		URL:       strings.TrimRight(url, "/"),
+		User:      user,
+		Pass:      pass,
+		ProfileID: profileID,
+		client:    &http.Client{Timeout: 30 * time.Second, Jar: jar},
+	}
+}
+
+type toLoginRequest struct {
+	User string `json:"u"`
+	Pass string `json:"p"`
+}
+
+// toProfileListResponse is the shape of a GET /api/4.0/profiles response:
+// like other Traffic Ops list/read endpoints, "response" is always an array,
+// even when filtered down to a single match by query string.
+type toProfileListResponse struct {
+	Response []Profile `json:"response"`
+}
+
+// login authenticates against Traffic Ops. The session cookie set on the
+// response is carried by t.client's cookie jar for every later request.
+func (t *TrafficOpsSource) login() error {
+	body, err := json.Marshal(toLoginRequest{User: t.User, Pass: t.Pass})
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(t.URL+"/api/4.0/user/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot log in to Traffic Ops at %s: %w", t.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Traffic Ops login to %s failed with status %s", t.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// Load fetches the profile identified by t.ProfileID from Traffic Ops.
+func (t *TrafficOpsSource) Load() (*Profile, error) {
+	if err := t.login(); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.client.Get(fmt.Sprintf("%s/api/4.0/profiles?id=%s", t.URL, t.ProfileID))
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch profile %s from Traffic Ops: %w", t.ProfileID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Traffic Ops returned status %s fetching profile %s", resp.Status, t.ProfileID)
+	}
+
+	var parsed toProfileListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("cannot parse Traffic Ops profile response: %w", err)
+	}
+
+	if len(parsed.Response) == 0 {
+		return nil, fmt.Errorf("Traffic Ops has no profile matching id %s", t.ProfileID)
+	}
+
+	return &parsed.Response[0], nil
+}
+
+// Save PUTs the converted profile back to Traffic Ops as profile
+// t.ProfileID.
+func (t *TrafficOpsSource) Save(profile *Profile) error {
+	if err := t.login(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("cannot encode profile for Traffic Ops: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/4.0/profiles/%s", t.URL, t.ProfileID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot push profile %s to Traffic Ops: %w", t.ProfileID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Traffic Ops returned status %s pushing profile %s", resp.Status, t.ProfileID)
+	}
+
+	return nil
+}
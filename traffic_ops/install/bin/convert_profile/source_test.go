@@ -0,0 +1,96 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSourceLoadReturnsErrorOnMissingFile(t *testing.T) {
+	_, err := FileSource{Path: filepath.Join(t.TempDir(), "does-not-exist.json")}.Load()
+	if err == nil {
+		t.Fatal("Load should return an error for a missing file, not panic")
+	}
+}
+
+func TestFileSourceLoadParsesProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profile.json")
+	data := `{"parameters":[{"name":"foo","config_file":"records.config","value":"bar"}],"profile":{"name":"EDGE"}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	profile, err := FileSource{Path: path}.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(profile.Parameters) != 1 || profile.Parameters[0].Name != "foo" {
+		t.Fatalf("got profile %+v, want a single \"foo\" parameter", profile)
+	}
+}
+
+func TestTrafficOpsSourceLoadDecodesArrayWrappedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/user/login"):
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/profiles"):
+			if r.URL.Query().Get("id") != "42" {
+				t.Errorf("expected id=42 query param, got %q", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"response":[{"parameters":[{"name":"foo","config_file":"records.config","value":"bar"}]}]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	source := NewTrafficOpsSource(server.URL, "user", "pass", "42")
+
+	profile, err := source.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(profile.Parameters) != 1 || profile.Parameters[0].Name != "foo" {
+		t.Fatalf("got profile %+v, want a single \"foo\" parameter", profile)
+	}
+}
+
+func TestTrafficOpsSourceLoadErrorsOnEmptyResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/user/login"):
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/profiles"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"response":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	source := NewTrafficOpsSource(server.URL, "user", "pass", "42")
+
+	if _, err := source.Load(); err == nil {
+		t.Fatal("Load should error when Traffic Ops returns no matching profile")
+	}
+}
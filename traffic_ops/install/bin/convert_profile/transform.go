@@ -0,0 +1,84 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"text/template"
+)
+
+// RegexReplace is the configuration for a ValueTransform's regex_replace
+// operation.
+type RegexReplace struct {
+	Pattern     string `json:"pattern" yaml:"pattern"`
+	Replacement string `json:"replacement" yaml:"replacement"`
+}
+
+// ValueTransform normalizes a matched parameter's value, as an alternative
+// to NewValue blindly overwriting it. Exactly one field is expected to be
+// set per rule; if more than one is, Append, Prepend, RegexReplace, IntAdd,
+// and Template are tried in that order.
+type ValueTransform struct {
+	Append       string        `json:"append,omitempty" yaml:"append,omitempty"`
+	Prepend      string        `json:"prepend,omitempty" yaml:"prepend,omitempty"`
+	RegexReplace *RegexReplace `json:"regex_replace,omitempty" yaml:"regex_replace,omitempty"`
+	IntAdd       *int          `json:"int_add,omitempty" yaml:"int_add,omitempty"`
+	Template     string        `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// apply computes the new value for param from value, the value already
+// produced by the rule's NewName/NewConfigFile/NewValue fields.
+func (vt *ValueTransform) apply(value string, param Parameter) (string, error) {
+	switch {
+	case vt.Append != "":
+		return value + vt.Append, nil
+
+	case vt.Prepend != "":
+		return vt.Prepend + value, nil
+
+	case vt.RegexReplace != nil:
+		re, err := regexp.Compile(vt.RegexReplace.Pattern)
+		if err != nil {
+			return value, fmt.Errorf("invalid regex_replace pattern %q: %w", vt.RegexReplace.Pattern, err)
+		}
+		return re.ReplaceAllString(value, vt.RegexReplace.Replacement), nil
+
+	case vt.IntAdd != nil:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return value, fmt.Errorf("int_add: value %q is not an integer: %w", value, err)
+		}
+		return strconv.Itoa(n + *vt.IntAdd), nil
+
+	case vt.Template != "":
+		tmpl, err := template.New("value_transform").Parse(vt.Template)
+		if err != nil {
+			return value, fmt.Errorf("invalid template: %w", err)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, param); err != nil {
+			return value, fmt.Errorf("template execution failed: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	return value, nil
+}
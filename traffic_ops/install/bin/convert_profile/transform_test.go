@@ -0,0 +1,109 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import "testing"
+
+func TestValueTransformAppend(t *testing.T) {
+	vt := ValueTransform{Append: "-suffix"}
+	got, err := vt.apply("base", Parameter{})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "base-suffix" {
+		t.Errorf("got %q, want %q", got, "base-suffix")
+	}
+}
+
+func TestValueTransformPrepend(t *testing.T) {
+	vt := ValueTransform{Prepend: "prefix-"}
+	got, err := vt.apply("base", Parameter{})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "prefix-base" {
+		t.Errorf("got %q, want %q", got, "prefix-base")
+	}
+}
+
+func TestValueTransformRegexReplace(t *testing.T) {
+	vt := ValueTransform{RegexReplace: &RegexReplace{Pattern: `^/opt/`, Replacement: "/usr/local/"}}
+	got, err := vt.apply("/opt/ats/bin", Parameter{})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "/usr/local/ats/bin" {
+		t.Errorf("got %q, want %q", got, "/usr/local/ats/bin")
+	}
+}
+
+func TestValueTransformRegexReplaceInvalidPattern(t *testing.T) {
+	vt := ValueTransform{RegexReplace: &RegexReplace{Pattern: `(`, Replacement: "x"}}
+	if _, err := vt.apply("anything", Parameter{}); err == nil {
+		t.Fatal("apply should return an error for an invalid regex_replace pattern")
+	}
+}
+
+func TestValueTransformIntAdd(t *testing.T) {
+	delta := 5
+	vt := ValueTransform{IntAdd: &delta}
+	got, err := vt.apply("10", Parameter{})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "15" {
+		t.Errorf("got %q, want %q", got, "15")
+	}
+}
+
+func TestValueTransformIntAddNonIntegerValue(t *testing.T) {
+	delta := 1
+	vt := ValueTransform{IntAdd: &delta}
+	if _, err := vt.apply("not-a-number", Parameter{}); err == nil {
+		t.Fatal("apply should return an error when the existing value isn't an integer")
+	}
+}
+
+func TestValueTransformTemplate(t *testing.T) {
+	vt := ValueTransform{Template: "{{.ConfigFile}}/{{.Name}}"}
+	param := Parameter{Name: "location", ConfigFile: "records.config"}
+	got, err := vt.apply("unused", param)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "records.config/location" {
+		t.Errorf("got %q, want %q", got, "records.config/location")
+	}
+}
+
+func TestValueTransformTemplateInvalid(t *testing.T) {
+	vt := ValueTransform{Template: "{{.Name"}
+	if _, err := vt.apply("unused", Parameter{}); err == nil {
+		t.Fatal("apply should return an error for a malformed template")
+	}
+}
+
+func TestValueTransformNoneSetReturnsValueUnchanged(t *testing.T) {
+	vt := ValueTransform{}
+	got, err := vt.apply("base", Parameter{})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got != "base" {
+		t.Errorf("got %q, want %q", got, "base")
+	}
+}
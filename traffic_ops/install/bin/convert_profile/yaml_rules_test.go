@@ -0,0 +1,107 @@
+/**
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConversionPolicyYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := `
+conversion_actions:
+  - match_parameter:
+      name: location
+      config_file: records.config
+      value: ""
+    new_value: /opt/ats
+  - new_name: CONFIG proxy.config.new_thing
+    new_config_file: records.config
+    new_value: "1"
+    action: add
+`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cp, err := parseConversionPolicy(path)
+	if err != nil {
+		t.Fatalf("parseConversionPolicy: %v", err)
+	}
+
+	if len(cp.ConversionRules) != 2 {
+		t.Fatalf("got %d conversion rules, want 2", len(cp.ConversionRules))
+	}
+	if cp.ConversionRules[1].Action != "add" {
+		t.Errorf("got action %q, want \"add\"", cp.ConversionRules[1].Action)
+	}
+}
+
+func TestConvertProfileAddAction(t *testing.T) {
+	profile := &Profile{Parameters: []Parameter{
+		{Name: "location", ConfigFile: "records.config", Value: "/opt"},
+	}}
+	rules := []ConversionRule{
+		{NewName: "CONFIG proxy.config.new_thing", NewConfigFile: "records.config", NewValue: "1", Action: "add"},
+	}
+
+	ConvertProfile(profile, rules, false, &ChangeLog{})
+
+	if len(profile.Parameters) != 2 {
+		t.Fatalf("got %d parameters, want 2", len(profile.Parameters))
+	}
+	added := profile.Parameters[1]
+	if added.Name != "CONFIG proxy.config.new_thing" || added.Value != "1" {
+		t.Errorf("got added parameter %+v, want name/value from the add rule", added)
+	}
+}
+
+func TestConvertProfileAddActionIsIdempotent(t *testing.T) {
+	profile := &Profile{Parameters: []Parameter{
+		{Name: "CONFIG proxy.config.new_thing", ConfigFile: "records.config", Value: "1"},
+	}}
+	rules := []ConversionRule{
+		{NewName: "CONFIG proxy.config.new_thing", NewConfigFile: "records.config", NewValue: "1", Action: "add"},
+	}
+
+	ConvertProfile(profile, rules, false, &ChangeLog{})
+
+	if len(profile.Parameters) != 1 {
+		t.Fatalf("got %d parameters, want 1 (add should skip an already-present parameter)", len(profile.Parameters))
+	}
+}
+
+func TestConvertProfileRenameOnlyAction(t *testing.T) {
+	profile := &Profile{Parameters: []Parameter{
+		{Name: "old_name", ConfigFile: "records.config", Value: "keep-me"},
+	}}
+	rules := []ConversionRule{
+		{MatchParameter: Parameter{Name: "old_name"}, NewName: "new_name", Action: "rename_only"},
+	}
+
+	ConvertProfile(profile, rules, false, &ChangeLog{})
+
+	if len(profile.Parameters) != 1 {
+		t.Fatalf("got %d parameters, want 1", len(profile.Parameters))
+	}
+	got := profile.Parameters[0]
+	if got.Name != "new_name" || got.Value != "keep-me" {
+		t.Errorf("got %+v, want name renamed but value untouched", got)
+	}
+}